@@ -6,18 +6,263 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
+	"sync"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-jsonrpc"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/apibstore"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/actors/adt"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/multisig"
 	"github.com/filecoin-project/lotus/chain/stmgr"
+	"github.com/filecoin-project/lotus/chain/store"
 	types "github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/wallet/key"
+	"github.com/filecoin-project/lotus/lib/sigs"
 	cid "github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
 	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/crypto/blake2b"
 	"golang.org/x/xerrors"
 )
 
+// MsgStatusEventType enumerates the lifecycle stages reported by SubscribeMessageStatus.
+type MsgStatusEventType string
+
+const (
+	// MsgStatusPending is emitted once, right after the subscription is established.
+	MsgStatusPending MsgStatusEventType = "pending"
+	// MsgStatusInMpool is emitted when the message is observed in the local mpool.
+	MsgStatusInMpool MsgStatusEventType = "inmpool"
+	// MsgStatusIncluded is emitted when the message is included in a block on the heaviest chain.
+	MsgStatusIncluded MsgStatusEventType = "included"
+	// MsgStatusExecuted is emitted once the message's receipt is available.
+	MsgStatusExecuted MsgStatusEventType = "executed"
+	// MsgStatusReplaced is emitted when a different message with the same from/nonce lands
+	// instead of the one being watched.
+	MsgStatusReplaced MsgStatusEventType = "replaced"
+	// MsgStatusDropped is emitted when the message leaves the mpool without ever being included.
+	MsgStatusDropped MsgStatusEventType = "dropped"
+)
+
+// MsgStatusEvent is a single lifecycle update for a message being watched by
+// SubscribeMessageStatus. Only the fields relevant to Type are populated.
+type MsgStatusEvent struct {
+	Type MsgStatusEventType
+
+	// Included
+	TipSet types.TipSetKey
+	Height abi.ChainEpoch
+
+	// Executed
+	Receipt *types.MessageReceipt
+
+	// Replaced
+	NewCid cid.Cid
+
+	// Dropped
+	Reason string
+}
+
+// FeePriority selects which percentile of recently observed gas premiums EstimateFees targets.
+type FeePriority string
+
+const (
+	FeePriorityLow    FeePriority = "low"
+	FeePriorityMedium FeePriority = "medium"
+	FeePriorityHigh   FeePriority = "high"
+)
+
+// defaultMaxBaseFeeMultiplier is used by EstimateFees and by Send/BuildMessage whenever
+// SendParams.MaxBaseFeeMultiplier is left unset.
+const defaultMaxBaseFeeMultiplier = 2.0
+
+// gasWindowSize is the number of most recent tipsets the GasOracle bases its estimates on.
+const gasWindowSize = 20
+
+// gasSample is what actually landed on chain for one tipset: the base fee it was mined against,
+// and the GasPremium of every message it included, sorted ascending.
+type gasSample struct {
+	baseFee  abi.TokenAmount
+	premiums []abi.TokenAmount
+}
+
+// GasOracle estimates GasPremium/GasFeeCap from a sliding window of recently included tipsets,
+// refreshed incrementally as new heads arrive over ChainNotify. A single instance is shared by
+// Send (when the caller leaves GasPremium/GasFeeCap zero) and by EstimateFees, so both paths
+// agree on what "the current market rate" means.
+type GasOracle struct {
+	api api.FullNode
+
+	startMu sync.Mutex
+	started bool
+
+	mu     sync.Mutex
+	window []gasSample
+	seen   map[types.TipSetKey]struct{}
+}
+
+// NewGasOracle constructs a GasOracle backed by a. The returned oracle does no work until its
+// first Estimate call.
+func NewGasOracle(a api.FullNode) *GasOracle {
+	return &GasOracle{
+		api:  a,
+		seen: map[types.TipSetKey]struct{}{},
+	}
+}
+
+// Estimate returns a suggested GasPremium and GasFeeCap for priority. maxBaseFeeMultiplier scales
+// the headroom added above the current base fee to GasFeeCap; a value <= 0 falls back to
+// defaultMaxBaseFeeMultiplier.
+func (g *GasOracle) Estimate(ctx context.Context, priority FeePriority, maxBaseFeeMultiplier float64) (premium abi.TokenAmount, feeCap abi.TokenAmount, err error) {
+	if err := g.ensureStarted(ctx); err != nil {
+		return abi.TokenAmount{}, abi.TokenAmount{}, err
+	}
+
+	pct := 50
+	switch priority {
+	case FeePriorityLow:
+		pct = 25
+	case FeePriorityHigh:
+		pct = 75
+	}
+
+	if maxBaseFeeMultiplier <= 0 {
+		maxBaseFeeMultiplier = defaultMaxBaseFeeMultiplier
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if len(g.window) == 0 {
+		return abi.TokenAmount{}, abi.TokenAmount{}, xerrors.Errorf("gas oracle has no chain data yet")
+	}
+
+	baseFee := g.window[len(g.window)-1].baseFee
+
+	var premiums []abi.TokenAmount
+	for _, s := range g.window {
+		premiums = append(premiums, s.premiums...)
+	}
+	sort.Slice(premiums, func(i, j int) bool { return premiums[i].LessThan(premiums[j]) })
+
+	premium = gasPercentile(premiums, pct)
+	if premium.IsZero() {
+		premium = abi.NewTokenAmount(1)
+	}
+
+	// keep two decimal digits of precision on the multiplier without leaving integer math
+	scaledBase := types.BigDiv(types.BigMul(baseFee, types.NewInt(uint64(maxBaseFeeMultiplier*100))), types.NewInt(100))
+	feeCap = types.BigAdd(scaledBase, premium)
+
+	return premium, feeCap, nil
+}
+
+func gasPercentile(sorted []abi.TokenAmount, pct int) abi.TokenAmount {
+	if len(sorted) == 0 {
+		return abi.NewTokenAmount(0)
+	}
+	idx := (len(sorted) - 1) * pct / 100
+	return sorted[idx]
+}
+
+// ensureStarted backfills the window from the current chain head on first use, then launches a
+// goroutine that keeps it up to date as new heads arrive. Unlike a sync.Once, a failed attempt
+// (e.g. a transient ChainHead/ChainNotify error) does not brick the oracle: started is only set
+// once the subscription is actually up, so the next call retries from scratch.
+func (g *GasOracle) ensureStarted(ctx context.Context) error {
+	g.startMu.Lock()
+	defer g.startMu.Unlock()
+
+	if g.started {
+		return nil
+	}
+
+	head, err := g.api.ChainHead(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	// walk back from head collecting newest-first, then fold into the window oldest-first so
+	// the last element is always the most recent tipset, matching what the ChainNotify
+	// goroutine below appends as new heads arrive
+	var chain []*types.TipSet
+	for ts := head; ts != nil && ts.Height() > 0 && len(chain) < gasWindowSize; {
+		chain = append(chain, ts)
+
+		parent, err := g.api.ChainGetTipSet(ctx, ts.Parents())
+		if err != nil {
+			break
+		}
+		ts = parent
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		g.addTipSet(ctx, chain[i])
+	}
+
+	changes, err := g.api.ChainNotify(ctx)
+	if err != nil {
+		return xerrors.Errorf("subscribing to chain notifications: %w", err)
+	}
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case cs, ok := <-changes:
+				if !ok {
+					return
+				}
+				for _, c := range cs {
+					if c.Type == store.HCApply || c.Type == store.HCCurrent {
+						g.addTipSet(ctx, c.Val)
+					}
+				}
+			}
+		}
+	}()
+
+	g.started = true
+	return nil
+}
+
+// addTipSet folds ts into the sliding window, evicting the oldest sample once the window is full.
+func (g *GasOracle) addTipSet(ctx context.Context, ts *types.TipSet) {
+	g.mu.Lock()
+	if _, ok := g.seen[ts.Key()]; ok {
+		g.mu.Unlock()
+		return
+	}
+	g.seen[ts.Key()] = struct{}{}
+	g.mu.Unlock()
+
+	var premiums []abi.TokenAmount
+	for _, b := range ts.Blocks() {
+		msgs, err := g.api.ChainGetBlockMessages(ctx, b.Cid())
+		if err != nil {
+			continue
+		}
+		for _, m := range msgs.BlsMessages {
+			premiums = append(premiums, m.GasPremium)
+		}
+		for _, m := range msgs.SecpkMessages {
+			premiums = append(premiums, m.Message.GasPremium)
+		}
+	}
+	sort.Slice(premiums, func(i, j int) bool { return premiums[i].LessThan(premiums[j]) })
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.window = append(g.window, gasSample{baseFee: ts.Blocks()[0].ParentBaseFee, premiums: premiums})
+	if len(g.window) > gasWindowSize {
+		g.window = g.window[len(g.window)-gasWindowSize:]
+	}
+}
+
 type ServicesAPI interface {
 	// Sends executes a send given SendParams
 	Send(ctx context.Context, params SendParams) (cid.Cid, error)
@@ -25,6 +270,46 @@ type ServicesAPI interface {
 	// paramaters to bytes of their CBOR encoding
 	DecodeTypedParamsFromJSON(ctx context.Context, to address.Address, method abi.MethodNum, paramstr string) ([]byte, error)
 
+	// BuildMessage constructs the unsigned types.Message that Send would submit, resolving the
+	// from address and gas fees the same way Send does, but stops short of signing or pushing it
+	// anywhere. The nonce is set only when params.Nonce.Set; otherwise it is left at 0 and is not
+	// looked up. The result can be serialized (CBOR or JSON) and handed off to a signer that
+	// never needs RPC access.
+	BuildMessage(ctx context.Context, params SendParams) (*types.Message, error)
+	// SignOffline signs msg with the key described by ki entirely locally, making no RPC calls.
+	// It is meant to run on an air-gapped machine against a message produced by BuildMessage on
+	// an online node.
+	SignOffline(ctx context.Context, msg *types.Message, ki types.KeyInfo) (*types.SignedMessage, error)
+	// BroadcastSigned pushes an already-signed message, such as one produced by SignOffline, to
+	// the mempool.
+	BroadcastSigned(ctx context.Context, sm *types.SignedMessage) (cid.Cid, error)
+
+	// SubscribeMessageStatus streams lifecycle events for a message previously pushed via Send,
+	// aggregating mpool and chain-head updates so callers don't have to poll StateSearchMsg. The
+	// returned channel is closed once the message reaches a terminal state (Executed, Replaced or
+	// Dropped) or ctx is done.
+	SubscribeMessageStatus(ctx context.Context, msg cid.Cid) (<-chan MsgStatusEvent, error)
+
+	// EstimateFees returns a suggested GasPremium/GasFeeCap pair for priority, computed by the
+	// same GasOracle that Send consults when a caller leaves those fields zero.
+	EstimateFees(ctx context.Context, priority FeePriority) (premium abi.TokenAmount, feeCap abi.TokenAmount, err error)
+
+	// SendBatch sends an ordered list of messages from the same From address as a single unit,
+	// assigning them sequential nonces starting from MpoolGetNonce and signing all of them before
+	// pushing any. See BatchOptions for all-or-nothing vs. best-effort push semantics.
+	SendBatch(ctx context.Context, batch []SendParams, opts BatchOptions) ([]cid.Cid, error)
+
+	// ProposeSend builds the inner call described by params the same way Send would, wraps it in
+	// a multisig Propose message addressed to msigAddr, and sends that instead. It returns both
+	// the outer message's CID and the TxnID the proposal was assigned.
+	ProposeSend(ctx context.Context, msigAddr address.Address, params SendParams) (cid.Cid, multisig.TxnID, error)
+	// ApproveTxn approves a pending multisig transaction, reading the multisig's actor state to
+	// recompute the exact proposal hash the actor expects so the caller doesn't have to track it.
+	ApproveTxn(ctx context.Context, msigAddr address.Address, id multisig.TxnID, txn SendParams) (cid.Cid, error)
+	// CancelTxn cancels a pending multisig transaction this account proposed, rebuilding its
+	// proposal hash the same way ApproveTxn does.
+	CancelTxn(ctx context.Context, msigAddr address.Address, id multisig.TxnID, txn SendParams) (cid.Cid, error)
+
 	// Close ends the session of services and disconnects from RPC, using Services after Close is called
 	// most likely will result in an error
 	// Should not be called concurrently
@@ -34,6 +319,18 @@ type ServicesAPI interface {
 type ServicesImpl struct {
 	api    api.FullNode
 	closer jsonrpc.ClientCloser
+
+	gasOracleOnce sync.Once
+	gasOracle     *GasOracle
+}
+
+// getGasOracle lazily constructs the GasOracle on first use, so ServicesImpl's zero-ish value
+// (as built by whatever wires up the api.FullNode) doesn't need to know about it up front.
+func (s *ServicesImpl) getGasOracle() *GasOracle {
+	s.gasOracleOnce.Do(func() {
+		s.gasOracle = NewGasOracle(s.api)
+	})
+	return s.gasOracle
 }
 
 func (s *ServicesImpl) Close() error {
@@ -62,8 +359,14 @@ func (s *ServicesImpl) DecodeTypedParamsFromJSON(ctx context.Context, to address
 		return nil, fmt.Errorf("unmarshaling input into params type: %w", err)
 	}
 
+	return marshalCBOR(p)
+}
+
+// marshalCBOR is the CBOR-encoding step DecodeTypedParamsFromJSON and the multisig helpers below
+// share: given a value that already knows how to serialize itself, return its encoded bytes.
+func marshalCBOR(m cbg.CBORMarshaler) ([]byte, error) {
 	buf := new(bytes.Buffer)
-	if err := p.MarshalCBOR(buf); err != nil {
+	if err := m.MarshalCBOR(buf); err != nil {
 		return nil, err
 	}
 	return buf.Bytes(), nil
@@ -86,6 +389,27 @@ type SendParams struct {
 	Params []byte
 
 	Force bool
+
+	// FeePriority selects the percentile the GasOracle targets when GasPremium/GasFeeCap are
+	// left zero. Defaults to FeePriorityMedium.
+	FeePriority FeePriority
+	// MaxBaseFeeMultiplier scales the headroom added above the current base fee when computing
+	// GasFeeCap. Defaults to defaultMaxBaseFeeMultiplier when <= 0.
+	MaxBaseFeeMultiplier float64
+}
+
+// BatchOptions controls how SendBatch pushes the messages it builds.
+type BatchOptions struct {
+	// AllOrNothing requests rollback-on-failure semantics: if pushing any message in the batch
+	// fails, every message already pushed is invalidated by replacing it with a zero-value
+	// self-transfer at the same nonce and higher fees, and SendBatch returns immediately with a
+	// nil slice. When false (the default), SendBatch keeps pushing the rest of the batch past a
+	// failed item and returns a slice index-aligned with batch -- cid.Undef for any item that
+	// failed to push -- alongside the first error encountered.
+	AllOrNothing bool
+	// Force skips SendBatch's up-front balance check, the batch-aware equivalent of
+	// SendParams.Force.
+	Force bool
 }
 
 // This is specialised Send for Send command
@@ -93,10 +417,35 @@ type SendParams struct {
 // We will see
 
 func (s *ServicesImpl) Send(ctx context.Context, params SendParams) (cid.Cid, error) {
+	msg, err := s.BuildMessage(ctx, params)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	if params.Nonce.Set {
+		sm, err := s.api.WalletSignMessage(ctx, msg.From, msg)
+		if err != nil {
+			return cid.Undef, err
+		}
+
+		return s.BroadcastSigned(ctx, sm)
+	}
+
+	sm, err := s.api.MpoolPushMessage(ctx, msg, nil)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	return sm.Cid(), nil
+}
+
+// BuildMessage constructs the unsigned message a Send call would otherwise build internally,
+// so that it can be carried to an offline signer instead of being signed and pushed right away.
+func (s *ServicesImpl) BuildMessage(ctx context.Context, params SendParams) (*types.Message, error) {
 	if params.From == address.Undef {
 		defaddr, err := s.api.WalletDefaultAddress(ctx)
 		if err != nil {
-			return cid.Undef, err
+			return nil, err
 		}
 		params.From = defaddr
 	}
@@ -114,39 +463,445 @@ func (s *ServicesImpl) Send(ctx context.Context, params SendParams) (cid.Cid, er
 		Params: params.Params,
 	}
 
+	if params.Nonce.Set {
+		msg.Nonce = params.Nonce.N
+	}
+
+	if msg.GasPremium == types.EmptyInt || msg.GasFeeCap == types.EmptyInt {
+		premium, feeCap, err := s.getGasOracle().Estimate(ctx, params.FeePriority, params.MaxBaseFeeMultiplier)
+		if err != nil {
+			return nil, xerrors.Errorf("estimating gas fees: %w", err)
+		}
+		if msg.GasPremium == types.EmptyInt {
+			msg.GasPremium = premium
+		}
+		if msg.GasFeeCap == types.EmptyInt {
+			msg.GasFeeCap = feeCap
+		}
+	}
+
 	if !params.Force {
 		// Funds insufficient check
 		fromBalance, err := s.api.WalletBalance(ctx, msg.From)
 		if err != nil {
-			return cid.Undef, err
+			return nil, err
 		}
 		totalCost := types.BigAdd(types.BigMul(msg.GasFeeCap, types.NewInt(uint64(msg.GasLimit))), msg.Value)
 
 		if fromBalance.LessThan(totalCost) {
 			fmt.Printf("WARNING: From balance %s less than total cost %s\n", types.FIL(fromBalance), types.FIL(totalCost))
-			return cid.Undef, fmt.Errorf("--force must be specified for this action to have an effect; you have been warned")
+			return nil, fmt.Errorf("--force must be specified for this action to have an effect; you have been warned")
 		}
 	}
 
-	if params.Nonce.Set {
-		msg.Nonce = params.Nonce.N
-		sm, err := s.api.WalletSignMessage(ctx, params.From, msg)
+	return msg, nil
+}
+
+// SignOffline signs msg with the key described by ki without making any RPC calls, so it can run
+// on a machine that has no connection to a node, such as a hardware wallet host or an HSM-backed
+// signer.
+func (s *ServicesImpl) SignOffline(ctx context.Context, msg *types.Message, ki types.KeyInfo) (*types.SignedMessage, error) {
+	k, err := key.NewKey(ki)
+	if err != nil {
+		return nil, xerrors.Errorf("loading key from key info: %w", err)
+	}
+
+	sig, err := sigs.Sign(key.ActSigType(k.Type), k.PrivateKey, msg.Cid().Bytes())
+	if err != nil {
+		return nil, xerrors.Errorf("signing message: %w", err)
+	}
+
+	return &types.SignedMessage{
+		Message:   *msg,
+		Signature: *sig,
+	}, nil
+}
+
+// BroadcastSigned submits an already-signed message, for example one produced by SignOffline, to
+// the mempool.
+func (s *ServicesImpl) BroadcastSigned(ctx context.Context, sm *types.SignedMessage) (cid.Cid, error) {
+	if _, err := s.api.MpoolPush(ctx, sm); err != nil {
+		return cid.Undef, err
+	}
+
+	return sm.Cid(), nil
+}
+
+// SendBatch sends every item in batch from a single From address, assigning them sequential
+// nonces starting from MpoolGetNonce and signing all of them before pushing any, so the batch
+// never races the mpool for nonces. It does a single up-front balance check across the whole
+// batch instead of SendParams.Force's usual per-message guard.
+func (s *ServicesImpl) SendBatch(ctx context.Context, batch []SendParams, opts BatchOptions) ([]cid.Cid, error) {
+	if len(batch) == 0 {
+		return nil, nil
+	}
+
+	from := batch[0].From
+	if from == address.Undef {
+		defaddr, err := s.api.WalletDefaultAddress(ctx)
 		if err != nil {
-			return cid.Undef, err
+			return nil, err
+		}
+		from = defaddr
+	}
+
+	nonce, err := s.api.MpoolGetNonce(ctx, from)
+	if err != nil {
+		return nil, xerrors.Errorf("getting starting nonce: %w", err)
+	}
+
+	msgs := make([]*types.Message, len(batch))
+	for i, p := range batch {
+		if p.From == address.Undef {
+			p.From = from
+		} else if p.From != from {
+			return nil, xerrors.Errorf("SendBatch requires every item to share the same From address: item %d has %s, expected %s", i, p.From, from)
 		}
 
-		_, err = s.api.MpoolPush(ctx, sm)
+		p.Nonce.Set = true
+		p.Nonce.N = nonce + uint64(i)
+		p.Force = true // the aggregate check below replaces the per-message one
+
+		msg, err := s.BuildMessage(ctx, p)
 		if err != nil {
-			return cid.Undef, err
+			return nil, xerrors.Errorf("building batch item %d: %w", i, err)
+		}
+		msgs[i] = msg
+	}
+
+	if !opts.Force {
+		total := types.NewInt(0)
+		for _, msg := range msgs {
+			total = types.BigAdd(total, types.BigAdd(types.BigMul(msg.GasFeeCap, types.NewInt(uint64(msg.GasLimit))), msg.Value))
 		}
 
-		return sm.Cid(), nil
+		fromBalance, err := s.api.WalletBalance(ctx, from)
+		if err != nil {
+			return nil, err
+		}
+		if fromBalance.LessThan(total) {
+			return nil, fmt.Errorf("WARNING: From balance %s less than total batch cost %s; BatchOptions.Force must be set for this action to have an effect; you have been warned", types.FIL(fromBalance), types.FIL(total))
+		}
 	}
 
-	sm, err := s.api.MpoolPushMessage(ctx, msg, nil)
+	sms := make([]*types.SignedMessage, len(msgs))
+	for i, msg := range msgs {
+		sm, err := s.api.WalletSignMessage(ctx, from, msg)
+		if err != nil {
+			return nil, xerrors.Errorf("signing batch item %d: %w", i, err)
+		}
+		sms[i] = sm
+	}
+
+	// cids is index-aligned with batch/sms: a failed item (best-effort mode only) leaves its slot
+	// as cid.Undef rather than shifting every later index down.
+	cids := make([]cid.Cid, len(sms))
+	var firstErr error
+	for i, sm := range sms {
+		c, err := s.BroadcastSigned(ctx, sm)
+		if err != nil {
+			if opts.AllOrNothing {
+				if rbErr := s.rollbackBatch(ctx, from, sms[:i]); rbErr != nil {
+					return nil, xerrors.Errorf("pushing batch item %d: %w (rollback of %d already-pushed items was incomplete, some may still be live in the mpool: %s)", i, err, i, rbErr)
+				}
+				return nil, xerrors.Errorf("pushing batch item %d, rolled back %d already-pushed items: %w", i, i, err)
+			}
+			if firstErr == nil {
+				firstErr = xerrors.Errorf("pushing batch item %d: %w", i, err)
+			}
+			continue
+		}
+		cids[i] = c
+	}
+
+	return cids, firstErr
+}
+
+// rollbackBatch invalidates already-pushed messages by replacing each one with a zero-value
+// self-transfer at the same nonce and double the fees, so it is guaranteed to supersede the
+// original in the mpool's replace-by-fee ordering. It returns an aggregated error if any
+// replacement fails to sign or push, since in that case the corresponding original message is
+// still live in the mpool and the caller's all-or-nothing guarantee no longer holds.
+func (s *ServicesImpl) rollbackBatch(ctx context.Context, from address.Address, pushed []*types.SignedMessage) error {
+	var failed []error
+	for _, sm := range pushed {
+		replacement := &types.Message{
+			From:       from,
+			To:         from,
+			Value:      types.NewInt(0),
+			Nonce:      sm.Message.Nonce,
+			GasLimit:   sm.Message.GasLimit,
+			GasFeeCap:  types.BigMul(sm.Message.GasFeeCap, types.NewInt(2)),
+			GasPremium: types.BigMul(sm.Message.GasPremium, types.NewInt(2)),
+		}
+
+		rsm, err := s.api.WalletSignMessage(ctx, from, replacement)
+		if err != nil {
+			failed = append(failed, xerrors.Errorf("signing replacement for nonce %d: %w", sm.Message.Nonce, err))
+			continue
+		}
+
+		if _, err := s.api.MpoolPush(ctx, rsm); err != nil {
+			failed = append(failed, xerrors.Errorf("pushing replacement for nonce %d: %w", sm.Message.Nonce, err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return xerrors.Errorf("%d of %d rollback replacements failed: %v", len(failed), len(pushed), failed)
+	}
+	return nil
+}
+
+// EstimateFees returns a suggested GasPremium/GasFeeCap pair for priority, using
+// defaultMaxBaseFeeMultiplier for the base-fee headroom. It shares its GasOracle, and therefore
+// its view of the current market rate, with Send.
+func (s *ServicesImpl) EstimateFees(ctx context.Context, priority FeePriority) (abi.TokenAmount, abi.TokenAmount, error) {
+	return s.getGasOracle().Estimate(ctx, priority, defaultMaxBaseFeeMultiplier)
+}
+
+// SubscribeMessageStatus aggregates mpool updates and chain head notifications into a single
+// stream of MsgStatusEvent for the given message, so a caller (e.g. `lotus send --wait`) can
+// observe inclusion/replacement without polling StateSearchMsg.
+func (s *ServicesImpl) SubscribeMessageStatus(ctx context.Context, msg cid.Cid) (<-chan MsgStatusEvent, error) {
+	mpoolUpdates, err := s.api.MpoolSub(ctx)
 	if err != nil {
-		return cid.Undef, err
+		return nil, xerrors.Errorf("subscribing to mpool updates: %w", err)
 	}
 
-	return sm.Cid(), nil
+	headUpdates, err := s.api.ChainNotify(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("subscribing to chain head updates: %w", err)
+	}
+
+	out := make(chan MsgStatusEvent, 16)
+
+	go func() {
+		defer close(out)
+
+		// sentinel distinct from every real MsgStatusEventType, so the very first emit (Pending)
+		// is never dropped by the ev.Type == lastType dedup check below
+		lastType := MsgStatusEventType("")
+		emit := func(ev MsgStatusEvent) {
+			if ev.Type == lastType {
+				// dedup repeats, most commonly caused by reorgs re-delivering the same head
+				return
+			}
+			lastType = ev.Type
+			select {
+			case out <- ev:
+			case <-ctx.Done():
+			}
+		}
+		emit(MsgStatusEvent{Type: MsgStatusPending})
+
+		var fromAddr address.Address
+		var nonce uint64
+		haveSender := false
+		seenTs := map[types.TipSetKey]struct{}{}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case u, ok := <-mpoolUpdates:
+				if !ok {
+					return
+				}
+				if u.Message == nil {
+					continue
+				}
+
+				if u.Message.Cid() == msg {
+					if !haveSender {
+						fromAddr, nonce, haveSender = u.Message.Message.From, u.Message.Message.Nonce, true
+					}
+					switch u.Type {
+					case api.MpoolAdd:
+						emit(MsgStatusEvent{Type: MsgStatusInMpool})
+					case api.MpoolRemove:
+						// mpool removal also happens when a message is included, racing against
+						// the ChainNotify/StateSearchMsg path below -- confirm it really is gone
+						// before calling it Dropped, and either way this is a terminal state
+						lookup, lerr := s.api.StateSearchMsg(ctx, types.EmptyTSK, msg, api.LookbackNoLimit, false)
+						if lerr == nil && lookup != nil {
+							emit(MsgStatusEvent{Type: MsgStatusIncluded, TipSet: lookup.TipSet, Height: lookup.Height})
+							emit(MsgStatusEvent{Type: MsgStatusExecuted, Receipt: &lookup.Receipt})
+						} else {
+							emit(MsgStatusEvent{Type: MsgStatusDropped, Reason: "removed from mpool without inclusion"})
+						}
+						return
+					}
+					continue
+				}
+
+				// a different message with the same from/nonce landed in our place
+				if haveSender && u.Type == api.MpoolAdd && u.Message.Message.From == fromAddr && u.Message.Message.Nonce == nonce {
+					emit(MsgStatusEvent{Type: MsgStatusReplaced, NewCid: u.Message.Cid()})
+					return
+				}
+
+			case changes, ok := <-headUpdates:
+				if !ok {
+					return
+				}
+				for _, change := range changes {
+					if change.Type != store.HCApply && change.Type != store.HCCurrent {
+						continue
+					}
+					if _, ok := seenTs[change.Val.Key()]; ok {
+						continue
+					}
+					seenTs[change.Val.Key()] = struct{}{}
+
+					lookup, err := s.api.StateSearchMsg(ctx, change.Val.Key(), msg, api.LookbackNoLimit, false)
+					if err != nil || lookup == nil {
+						continue
+					}
+
+					emit(MsgStatusEvent{Type: MsgStatusIncluded, TipSet: lookup.TipSet, Height: lookup.Height})
+					emit(MsgStatusEvent{Type: MsgStatusExecuted, Receipt: &lookup.Receipt})
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// ProposeSend wraps the call params describes in a multisig Propose message addressed to
+// msigAddr and sends that through the usual Send pipeline, rather than hand-encoding the
+// multisig.ProposeParams at every call site.
+func (s *ServicesImpl) ProposeSend(ctx context.Context, msigAddr address.Address, params SendParams) (cid.Cid, multisig.TxnID, error) {
+	encoded, err := marshalCBOR(&multisig.ProposeParams{
+		To:     params.To,
+		Value:  params.Val,
+		Method: params.Method,
+		Params: params.Params,
+	})
+	if err != nil {
+		return cid.Undef, -1, xerrors.Errorf("encoding propose params: %w", err)
+	}
+
+	outer := params
+	outer.To = msigAddr
+	outer.Val = types.NewInt(0)
+	outer.Method = multisig.Methods.Propose
+	outer.Params = encoded
+
+	c, err := s.Send(ctx, outer)
+	if err != nil {
+		return cid.Undef, -1, err
+	}
+
+	lookup, err := s.api.StateWaitMsg(ctx, c, build.MessageConfidence, api.LookbackNoLimit, true)
+	if err != nil {
+		return c, -1, xerrors.Errorf("waiting for propose message: %w", err)
+	}
+	if lookup.Receipt.ExitCode.IsError() {
+		return c, -1, xerrors.Errorf("propose failed with exit code %d", lookup.Receipt.ExitCode)
+	}
+
+	var ret multisig.ProposeReturn
+	if err := ret.UnmarshalCBOR(bytes.NewReader(lookup.Receipt.Return)); err != nil {
+		return c, -1, xerrors.Errorf("decoding propose return: %w", err)
+	}
+
+	return c, ret.TxnID, nil
+}
+
+// ApproveTxn approves the pending multisig transaction id, reading msigAddr's actor state to
+// recompute the exact proposal hash the actor expects. Only txn.From, its gas-related fields,
+// txn.Nonce and txn.Force are used; txn.To, txn.Val, txn.Method, and txn.Params are discarded:
+// the message goes to msigAddr with zero value and TxnIDParams whose proposal hash is computed
+// from the pending transaction's on-chain state, so callers should not expect values set there
+// to take effect.
+func (s *ServicesImpl) ApproveTxn(ctx context.Context, msigAddr address.Address, id multisig.TxnID, txn SendParams) (cid.Cid, error) {
+	return s.sendTxnID(ctx, msigAddr, multisig.Methods.Approve, id, txn)
+}
+
+// CancelTxn cancels the pending multisig transaction id that this account proposed, rebuilding
+// its proposal hash the same way ApproveTxn does. As with ApproveTxn, only txn.From, its
+// gas-related fields, txn.Nonce and txn.Force are used; txn.To, txn.Val, txn.Method, and
+// txn.Params are discarded.
+func (s *ServicesImpl) CancelTxn(ctx context.Context, msigAddr address.Address, id multisig.TxnID, txn SendParams) (cid.Cid, error) {
+	return s.sendTxnID(ctx, msigAddr, multisig.Methods.Cancel, id, txn)
+}
+
+// sendTxnID is the shared implementation behind ApproveTxn and CancelTxn: both just send a
+// multisig.TxnIDParams wrapping id and a locally-recomputed proposal hash to a different method.
+func (s *ServicesImpl) sendTxnID(ctx context.Context, msigAddr address.Address, method abi.MethodNum, id multisig.TxnID, txn SendParams) (cid.Cid, error) {
+	pending, err := s.pendingMsigTxn(ctx, msigAddr, id)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("looking up pending transaction %d on %s: %w", id, msigAddr, err)
+	}
+
+	hash, err := multisig.ComputeProposalHash(pending, func(data []byte) ([32]byte, error) {
+		return blake2b.Sum256(data), nil
+	})
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("computing proposal hash: %w", err)
+	}
+
+	encoded, err := marshalCBOR(&multisig.TxnIDParams{ID: id, ProposalHash: hash})
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("encoding txn id params: %w", err)
+	}
+
+	outer := txn
+	outer.To = msigAddr
+	outer.Val = types.NewInt(0)
+	outer.Method = method
+	outer.Params = encoded
+
+	c, err := s.Send(ctx, outer)
+	if err != nil {
+		return c, err
+	}
+
+	lookup, err := s.api.StateWaitMsg(ctx, c, build.MessageConfidence, api.LookbackNoLimit, true)
+	if err != nil {
+		return c, xerrors.Errorf("waiting for approve/cancel message: %w", err)
+	}
+	if lookup.Receipt.ExitCode.IsError() {
+		return c, xerrors.Errorf("approve/cancel failed with exit code %d", lookup.Receipt.ExitCode)
+	}
+
+	return c, nil
+}
+
+// pendingMsigTxn loads msigAddr's actor state and returns the on-chain multisig.Transaction
+// pending under id, including the real Approved list so ComputeProposalHash agrees with what the
+// actor itself will compute -- the list grows by one address per prior Approve, so anything short
+// of the live state (e.g. assuming only the proposer has approved) produces the wrong hash for
+// every approval after the first.
+func (s *ServicesImpl) pendingMsigTxn(ctx context.Context, msigAddr address.Address, id multisig.TxnID) (*multisig.Transaction, error) {
+	act, err := s.api.StateGetActor(ctx, msigAddr, types.EmptyTSK)
+	if err != nil {
+		return nil, err
+	}
+
+	store := adt.WrapStore(ctx, cbor.NewCborStore(apibstore.NewAPIBlockstore(s.api)))
+	st, err := multisig.Load(store, act)
+	if err != nil {
+		return nil, xerrors.Errorf("loading multisig state: %w", err)
+	}
+
+	var found *multisig.Transaction
+	if err := st.ForEachPendingTxn(func(txnID int64, txn multisig.Transaction) error {
+		if multisig.TxnID(txnID) == id {
+			txn := txn
+			found = &txn
+		}
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("iterating pending transactions: %w", err)
+	}
+
+	if found == nil {
+		return nil, xerrors.Errorf("no pending transaction %d", id)
+	}
+
+	return found, nil
 }
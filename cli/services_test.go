@@ -0,0 +1,397 @@
+package cli
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/crypto"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/store"
+	types "github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/types/mock"
+	cid "github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+)
+
+// fakeFullNode implements api.FullNode by embedding a nil instance and overriding only the
+// methods exercised by the test at hand; any call the test doesn't wire up panics on the nil
+// embed, which is the point -- it means the code under test reached further than expected.
+type fakeFullNode struct {
+	api.FullNode
+
+	chainHead             func(ctx context.Context) (*types.TipSet, error)
+	chainGetTipSet        func(ctx context.Context, tsk types.TipSetKey) (*types.TipSet, error)
+	chainGetBlockMessages func(ctx context.Context, b cid.Cid) (*api.BlockMessages, error)
+	chainNotify           func(ctx context.Context) (<-chan []*store.HeadChange, error)
+
+	mpoolSub       func(ctx context.Context) (<-chan api.MpoolUpdate, error)
+	stateSearchMsg func(ctx context.Context, tsk types.TipSetKey, msg cid.Cid, limit abi.ChainEpoch, allowReplaced bool) (*api.MsgLookup, error)
+
+	walletDefaultAddress func(ctx context.Context) (address.Address, error)
+	mpoolGetNonce        func(ctx context.Context, addr address.Address) (uint64, error)
+	walletBalance        func(ctx context.Context, addr address.Address) (types.BigInt, error)
+	walletSignMessage    func(ctx context.Context, addr address.Address, msg *types.Message) (*types.SignedMessage, error)
+	mpoolPush            func(ctx context.Context, sm *types.SignedMessage) (cid.Cid, error)
+}
+
+func (f *fakeFullNode) ChainHead(ctx context.Context) (*types.TipSet, error) {
+	return f.chainHead(ctx)
+}
+
+func (f *fakeFullNode) ChainGetTipSet(ctx context.Context, tsk types.TipSetKey) (*types.TipSet, error) {
+	return f.chainGetTipSet(ctx, tsk)
+}
+
+func (f *fakeFullNode) ChainGetBlockMessages(ctx context.Context, b cid.Cid) (*api.BlockMessages, error) {
+	return f.chainGetBlockMessages(ctx, b)
+}
+
+func (f *fakeFullNode) ChainNotify(ctx context.Context) (<-chan []*store.HeadChange, error) {
+	return f.chainNotify(ctx)
+}
+
+func (f *fakeFullNode) MpoolSub(ctx context.Context) (<-chan api.MpoolUpdate, error) {
+	return f.mpoolSub(ctx)
+}
+
+func (f *fakeFullNode) StateSearchMsg(ctx context.Context, tsk types.TipSetKey, msg cid.Cid, limit abi.ChainEpoch, allowReplaced bool) (*api.MsgLookup, error) {
+	return f.stateSearchMsg(ctx, tsk, msg, limit, allowReplaced)
+}
+
+func (f *fakeFullNode) WalletDefaultAddress(ctx context.Context) (address.Address, error) {
+	return f.walletDefaultAddress(ctx)
+}
+
+func (f *fakeFullNode) MpoolGetNonce(ctx context.Context, addr address.Address) (uint64, error) {
+	return f.mpoolGetNonce(ctx, addr)
+}
+
+func (f *fakeFullNode) WalletBalance(ctx context.Context, addr address.Address) (types.BigInt, error) {
+	return f.walletBalance(ctx, addr)
+}
+
+func (f *fakeFullNode) WalletSignMessage(ctx context.Context, addr address.Address, msg *types.Message) (*types.SignedMessage, error) {
+	return f.walletSignMessage(ctx, addr, msg)
+}
+
+func (f *fakeFullNode) MpoolPush(ctx context.Context, sm *types.SignedMessage) (cid.Cid, error) {
+	return f.mpoolPush(ctx, sm)
+}
+
+func withBaseFee(h *types.BlockHeader, fee uint64) *types.BlockHeader {
+	h.ParentBaseFee = types.NewInt(fee)
+	return h
+}
+
+// TestGasOracleBackfillOrdering is a regression test for a bug where ensureStarted's backfill
+// loop appended tipsets in head-to-genesis traversal order, leaving the window newest-first
+// while Estimate reads window[len-1] expecting newest-last. It would have reported the oldest
+// backfilled tipset's base fee instead of the head's.
+func TestGasOracleBackfillOrdering(t *testing.T) {
+	grandparent := mock.TipSet(withBaseFee(mock.MkBlock(nil, 1, 1), 100))
+	parent := mock.TipSet(withBaseFee(mock.MkBlock(grandparent, 1, 2), 200))
+	head := mock.TipSet(withBaseFee(mock.MkBlock(parent, 1, 3), 300))
+
+	tipsets := map[types.TipSetKey]*types.TipSet{
+		grandparent.Key(): grandparent,
+		parent.Key():      parent,
+		head.Key():        head,
+	}
+
+	fake := &fakeFullNode{
+		chainHead: func(ctx context.Context) (*types.TipSet, error) { return head, nil },
+		chainGetTipSet: func(ctx context.Context, tsk types.TipSetKey) (*types.TipSet, error) {
+			ts, ok := tipsets[tsk]
+			if !ok {
+				return nil, xerrors.Errorf("no such tipset")
+			}
+			return ts, nil
+		},
+		chainGetBlockMessages: func(ctx context.Context, b cid.Cid) (*api.BlockMessages, error) {
+			return &api.BlockMessages{}, nil
+		},
+		chainNotify: func(ctx context.Context) (<-chan []*store.HeadChange, error) {
+			return make(chan []*store.HeadChange), nil
+		},
+	}
+
+	g := NewGasOracle(fake)
+	_, feeCap, err := g.Estimate(context.Background(), FeePriorityMedium, 1)
+	if err != nil {
+		t.Fatalf("Estimate: %v", err)
+	}
+
+	// with no messages in any block, premium falls back to the 1 attoFIL floor, so subtracting
+	// it isolates the base fee the oracle actually used.
+	got := types.BigSub(feeCap, types.NewInt(1))
+	if !got.Equals(types.NewInt(300)) {
+		t.Fatalf("expected window to end on head's base fee 300, got %s (regression: backfill order)", got)
+	}
+}
+
+func mkSignedMessage(t *testing.T, from, to address.Address, nonce uint64) *types.SignedMessage {
+	t.Helper()
+	msg := types.Message{
+		From:       from,
+		To:         to,
+		Value:      types.NewInt(0),
+		Nonce:      nonce,
+		GasLimit:   1,
+		GasFeeCap:  types.NewInt(1),
+		GasPremium: types.NewInt(1),
+	}
+	// BLS signatures make SignedMessage.Cid() equal the inner Message.Cid(), which is what
+	// SubscribeMessageStatus compares against.
+	return &types.SignedMessage{
+		Message:   msg,
+		Signature: crypto.Signature{Type: crypto.SigTypeBLS},
+	}
+}
+
+// TestSubscribeMessageStatusPendingFirst is a regression test for a bug where lastType was
+// seeded to MsgStatusPending itself, so the very first emit was deduped away before ever
+// reaching the caller.
+func TestSubscribeMessageStatusPendingFirst(t *testing.T) {
+	from, _ := address.NewIDAddress(100)
+	to, _ := address.NewIDAddress(101)
+	sm := mkSignedMessage(t, from, to, 1)
+
+	mpoolUpdates := make(chan api.MpoolUpdate, 4)
+	headUpdates := make(chan []*store.HeadChange, 4)
+
+	fake := &fakeFullNode{
+		mpoolSub:    func(ctx context.Context) (<-chan api.MpoolUpdate, error) { return mpoolUpdates, nil },
+		chainNotify: func(ctx context.Context) (<-chan []*store.HeadChange, error) { return headUpdates, nil },
+	}
+
+	s := &ServicesImpl{api: fake}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := s.SubscribeMessageStatus(ctx, sm.Cid())
+	if err != nil {
+		t.Fatalf("SubscribeMessageStatus: %v", err)
+	}
+
+	first := <-events
+	if first.Type != MsgStatusPending {
+		t.Fatalf("expected first event to be Pending, got %s (regression: Pending dropped by dedup)", first.Type)
+	}
+
+	mpoolUpdates <- api.MpoolUpdate{Type: api.MpoolAdd, Message: sm}
+	second := <-events
+	if second.Type != MsgStatusInMpool {
+		t.Fatalf("expected InMpool after the mpool add, got %s", second.Type)
+	}
+}
+
+// TestSubscribeMessageStatusIncludedRace is a regression test for a bug where an MpoolRemove
+// racing with inclusion was always reported as Dropped, even when the message had actually
+// landed on chain.
+func TestSubscribeMessageStatusIncludedRace(t *testing.T) {
+	from, _ := address.NewIDAddress(100)
+	to, _ := address.NewIDAddress(101)
+	sm := mkSignedMessage(t, from, to, 1)
+
+	mpoolUpdates := make(chan api.MpoolUpdate, 4)
+	headUpdates := make(chan []*store.HeadChange, 4)
+
+	fake := &fakeFullNode{
+		mpoolSub:    func(ctx context.Context) (<-chan api.MpoolUpdate, error) { return mpoolUpdates, nil },
+		chainNotify: func(ctx context.Context) (<-chan []*store.HeadChange, error) { return headUpdates, nil },
+		stateSearchMsg: func(ctx context.Context, tsk types.TipSetKey, msg cid.Cid, limit abi.ChainEpoch, allowReplaced bool) (*api.MsgLookup, error) {
+			return &api.MsgLookup{Height: 10}, nil
+		},
+	}
+
+	s := &ServicesImpl{api: fake}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := s.SubscribeMessageStatus(ctx, sm.Cid())
+	if err != nil {
+		t.Fatalf("SubscribeMessageStatus: %v", err)
+	}
+	<-events // Pending
+
+	mpoolUpdates <- api.MpoolUpdate{Type: api.MpoolAdd, Message: sm}
+	<-events // InMpool
+
+	mpoolUpdates <- api.MpoolUpdate{Type: api.MpoolRemove, Message: sm}
+
+	included := <-events
+	if included.Type != MsgStatusIncluded {
+		t.Fatalf("expected Included when StateSearchMsg finds the message despite mpool removal, got %s (regression: false Dropped on race)", included.Type)
+	}
+	executed := <-events
+	if executed.Type != MsgStatusExecuted {
+		t.Fatalf("expected Executed to follow Included, got %s", executed.Type)
+	}
+
+	if _, ok := <-events; ok {
+		t.Fatalf("expected the channel to close after a terminal event")
+	}
+}
+
+// TestSubscribeMessageStatusDropped covers the companion case: an MpoolRemove where
+// StateSearchMsg genuinely finds nothing is reported as Dropped and terminal.
+func TestSubscribeMessageStatusDropped(t *testing.T) {
+	from, _ := address.NewIDAddress(100)
+	to, _ := address.NewIDAddress(101)
+	sm := mkSignedMessage(t, from, to, 1)
+
+	mpoolUpdates := make(chan api.MpoolUpdate, 4)
+	headUpdates := make(chan []*store.HeadChange, 4)
+
+	fake := &fakeFullNode{
+		mpoolSub:    func(ctx context.Context) (<-chan api.MpoolUpdate, error) { return mpoolUpdates, nil },
+		chainNotify: func(ctx context.Context) (<-chan []*store.HeadChange, error) { return headUpdates, nil },
+		stateSearchMsg: func(ctx context.Context, tsk types.TipSetKey, msg cid.Cid, limit abi.ChainEpoch, allowReplaced bool) (*api.MsgLookup, error) {
+			return nil, xerrors.Errorf("not found")
+		},
+	}
+
+	s := &ServicesImpl{api: fake}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := s.SubscribeMessageStatus(ctx, sm.Cid())
+	if err != nil {
+		t.Fatalf("SubscribeMessageStatus: %v", err)
+	}
+	<-events // Pending
+
+	mpoolUpdates <- api.MpoolUpdate{Type: api.MpoolRemove, Message: sm}
+
+	dropped := <-events
+	if dropped.Type != MsgStatusDropped {
+		t.Fatalf("expected Dropped when StateSearchMsg finds nothing, got %s", dropped.Type)
+	}
+
+	if _, ok := <-events; ok {
+		t.Fatalf("expected the channel to close after a terminal event")
+	}
+}
+
+func newBatchTestServices(walletSignMessage func(ctx context.Context, addr address.Address, msg *types.Message) (*types.SignedMessage, error), mpoolPush func(ctx context.Context, sm *types.SignedMessage) (cid.Cid, error)) *ServicesImpl {
+	fake := &fakeFullNode{
+		mpoolGetNonce:     func(ctx context.Context, addr address.Address) (uint64, error) { return 5, nil },
+		walletBalance:     func(ctx context.Context, addr address.Address) (types.BigInt, error) { return types.NewInt(1_000_000_000_000), nil },
+		walletSignMessage: walletSignMessage,
+		mpoolPush:         mpoolPush,
+	}
+	return &ServicesImpl{api: fake}
+}
+
+func signMessageNoop(ctx context.Context, addr address.Address, msg *types.Message) (*types.SignedMessage, error) {
+	return &types.SignedMessage{Message: *msg, Signature: crypto.Signature{Type: crypto.SigTypeBLS}}, nil
+}
+
+// TestSendBatchBestEffortContinuesPastFailure is a regression test for a bug where the
+// best-effort push loop returned on the first failure instead of continuing through the rest of
+// the batch.
+func TestSendBatchBestEffortContinuesPastFailure(t *testing.T) {
+	from, _ := address.NewIDAddress(100)
+	to, _ := address.NewIDAddress(101)
+
+	var pushed []uint64
+	s := newBatchTestServices(signMessageNoop, func(ctx context.Context, sm *types.SignedMessage) (cid.Cid, error) {
+		if sm.Message.Nonce == 6 {
+			return cid.Undef, xerrors.Errorf("injected failure")
+		}
+		pushed = append(pushed, sm.Message.Nonce)
+		return sm.Cid(), nil
+	})
+
+	batch := []SendParams{
+		{From: from, To: to, Val: types.NewInt(1), GasLimit: 1, GasFeeCap: types.NewInt(1), GasPremium: types.NewInt(1)},
+		{From: from, To: to, Val: types.NewInt(1), GasLimit: 1, GasFeeCap: types.NewInt(1), GasPremium: types.NewInt(1)},
+		{From: from, To: to, Val: types.NewInt(1), GasLimit: 1, GasFeeCap: types.NewInt(1), GasPremium: types.NewInt(1)},
+	}
+
+	cids, err := s.SendBatch(context.Background(), batch, BatchOptions{})
+	if err == nil {
+		t.Fatalf("expected the injected push failure to surface as an error")
+	}
+	if len(cids) != 3 {
+		t.Fatalf("expected an index-aligned result for every batch item, got %d", len(cids))
+	}
+	if cids[1] != cid.Undef {
+		t.Fatalf("expected the failed item's slot to be cid.Undef, got %s", cids[1])
+	}
+	if cids[0] == cid.Undef || cids[2] == cid.Undef {
+		t.Fatalf("expected items before and after the failure to still push in best-effort mode (regression: fail-fast bug)")
+	}
+	if len(pushed) != 2 {
+		t.Fatalf("expected 2 successful pushes, got %d", len(pushed))
+	}
+}
+
+// TestSendBatchAllOrNothingRollsBack checks that a failure mid-batch in AllOrNothing mode rolls
+// back every already-pushed item and returns a nil result.
+func TestSendBatchAllOrNothingRollsBack(t *testing.T) {
+	from, _ := address.NewIDAddress(100)
+	to, _ := address.NewIDAddress(101)
+
+	var rollbackPushes int
+	s := newBatchTestServices(signMessageNoop, func(ctx context.Context, sm *types.SignedMessage) (cid.Cid, error) {
+		if sm.Message.To == to {
+			if sm.Message.Nonce == 6 {
+				return cid.Undef, xerrors.Errorf("injected failure")
+			}
+			return sm.Cid(), nil
+		}
+		rollbackPushes++
+		return sm.Cid(), nil
+	})
+
+	batch := []SendParams{
+		{From: from, To: to, Val: types.NewInt(1), GasLimit: 1, GasFeeCap: types.NewInt(1), GasPremium: types.NewInt(1)},
+		{From: from, To: to, Val: types.NewInt(1), GasLimit: 1, GasFeeCap: types.NewInt(1), GasPremium: types.NewInt(1)},
+	}
+
+	cids, err := s.SendBatch(context.Background(), batch, BatchOptions{AllOrNothing: true})
+	if err == nil {
+		t.Fatalf("expected an error when a batch item fails to push in all-or-nothing mode")
+	}
+	if cids != nil {
+		t.Fatalf("expected a nil result on all-or-nothing failure, got %v", cids)
+	}
+	if rollbackPushes != 1 {
+		t.Fatalf("expected the one already-pushed item to be rolled back, got %d rollback pushes", rollbackPushes)
+	}
+}
+
+// TestSendBatchAllOrNothingSurfacesRollbackFailure is a regression test for rollbackBatch
+// silently swallowing sign/push errors: if a rollback replacement itself fails to push, the
+// caller must learn about it instead of being told the batch was cleanly rolled back.
+func TestSendBatchAllOrNothingSurfacesRollbackFailure(t *testing.T) {
+	from, _ := address.NewIDAddress(100)
+	to, _ := address.NewIDAddress(101)
+
+	s := newBatchTestServices(signMessageNoop, func(ctx context.Context, sm *types.SignedMessage) (cid.Cid, error) {
+		if sm.Message.To == to {
+			if sm.Message.Nonce == 6 {
+				return cid.Undef, xerrors.Errorf("injected failure")
+			}
+			return sm.Cid(), nil
+		}
+		return cid.Undef, xerrors.Errorf("injected rollback failure")
+	})
+
+	batch := []SendParams{
+		{From: from, To: to, Val: types.NewInt(1), GasLimit: 1, GasFeeCap: types.NewInt(1), GasPremium: types.NewInt(1)},
+		{From: from, To: to, Val: types.NewInt(1), GasLimit: 1, GasFeeCap: types.NewInt(1), GasPremium: types.NewInt(1)},
+	}
+
+	_, err := s.SendBatch(context.Background(), batch, BatchOptions{AllOrNothing: true})
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	if !strings.Contains(err.Error(), "rollback") {
+		t.Fatalf("expected the push error to mention the failed rollback so the caller knows the all-or-nothing guarantee was violated, got: %v", err)
+	}
+}